@@ -2,25 +2,49 @@ package cmd
 
 import (
 	"fmt"
+	"io/fs"
 	"os"
 	"os/exec"
 	"path"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"text/template"
 
+	"github.com/go-shana/cmd/shana/internal/goinvoke"
 	"github.com/go-shana/core/errors"
 	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
 )
 
+// templateManifestName is the optional manifest file a remote template
+// repository can ship to declare default template variables.
+const templateManifestName = "shana.tmpl.yaml"
+
 type cmdCreateContext struct {
 	PkgName      string
 	Project      string
 	ShanaCorePkg string
+
+	// Vars holds user-defined template variables from the template's
+	// shana.tmpl.yaml manifest and the --set flag.
+	Vars map[string]string
+}
+
+// templateManifest is the schema of a template repository's optional
+// shana.tmpl.yaml manifest.
+type templateManifest struct {
+	Vars map[string]string `yaml:"vars"`
 }
 
 func init() {
 	rootCmd.AddCommand(createCmd)
+
+	createCmd.Flags().String("remote", "", "Git repository of custom project templates")
+	createCmd.Flags().String("branch", "main", "Branch of the remote template repository to use")
+	createCmd.Flags().String("home", "", "Directory to cache remote templates (default $XDG_CACHE_HOME/shana/templates or ~/.shana/templates)")
+	createCmd.Flags().String("template", "", "Name of the template to use, e.g. 'minimal', 'httpjson' (requires --remote)")
+	createCmd.Flags().StringArray("set", nil, "Set a template variable in the form key=val, can be repeated")
 }
 
 // createCmd represents the new command
@@ -35,11 +59,18 @@ For example:
 
 	shana create repo.example.com/my-project
 
-It will create a new directory named 'my-project' in the current directory.`,
+It will create a new directory named 'my-project' in the current directory.
+
+Use '--remote' to generate the project from a git repository of custom
+templates instead of the built-in ones, optionally picking a subdirectory
+with '--template' and overriding template variables with '--set key=val'.`,
 	Args: cobra.MinimumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) (err error) {
 		defer errors.Handle(&err)
 
+		ctx, cancel := commandContext(cmd)
+		defer cancel()
+
 		pkgName := args[0]
 
 		if !pkgRegexp.MatchString(pkgName) {
@@ -53,6 +84,17 @@ It will create a new directory named 'my-project' in the current directory.`,
 			projectName = args[1]
 		}
 
+		remote := errors.Check1(cmd.Flags().GetString("remote"))
+		branch := errors.Check1(cmd.Flags().GetString("branch"))
+		home := errors.Check1(cmd.Flags().GetString("home"))
+		templateName := errors.Check1(cmd.Flags().GetString("template"))
+		sets := errors.Check1(cmd.Flags().GetStringArray("set"))
+
+		if templateName != "" && remote == "" {
+			errors.Throwf("--template requires --remote to be set")
+			return
+		}
+
 		errors.Check(os.MkdirAll(projectName, 0755))
 
 		project := normalizeProjectName(projectName)
@@ -60,22 +102,53 @@ It will create a new directory named 'my-project' in the current directory.`,
 			PkgName:      pkgName,
 			Project:      project,
 			ShanaCorePkg: shanaCorePackage,
+			Vars:         parseSetFlags(sets),
+		}
+
+		var files []templateFile
+
+		if remote == "" {
+			for _, tmpl := range listCreateTemplates() {
+				files = append(files, templateFile{name: tmpl.Name(), tmpl: tmpl})
+			}
+		} else {
+			templateDir := fetchRemoteTemplate(remote, branch, home)
+
+			if templateName != "" {
+				templateDir = path.Join(templateDir, templateName)
+			}
+
+			manifest := loadTemplateManifest(templateDir)
+
+			for k, v := range manifest.Vars {
+				if _, ok := cmdContext.Vars[k]; !ok {
+					cmdContext.Vars[k] = v
+				}
+			}
+
+			files = loadTemplatesFromDir(templateDir)
 		}
 
-		templates := listCreateTemplates()
+		for _, f := range files {
+			filename := path.Join(projectName, f.name)
+			errors.Check(os.MkdirAll(path.Dir(filename), 0755))
 
-		for _, tmpl := range templates {
-			createFile(path.Join(projectName, tmpl.Name()), tmpl, cmdContext)
+			if f.tmpl != nil {
+				createFile(filename, f.tmpl, cmdContext)
+			} else {
+				errors.Check(os.WriteFile(filename, f.raw, 0644))
+			}
 		}
 
 		fmt.Fprintln(os.Stderr, "Project is created. Running 'go mod tidy' to install dependencies.")
 		fmt.Fprintln(os.Stderr)
 
-		command := exec.Command("go", "mod", "tidy")
-		command.Stdout = os.Stdout
-		command.Stderr = os.Stderr
-		command.Dir = projectName
-		errors.Check(command.Run())
+		tidy := goinvoke.Invocation{
+			Verb:       "mod",
+			Args:       []string{"tidy"},
+			WorkingDir: projectName,
+		}
+		errors.Check(tidy.Run(ctx))
 
 		return
 	},
@@ -189,3 +262,145 @@ shana:
 		shanaYAMLTemplate,
 	}
 }
+
+// parseSetFlags parses repeated "key=val" arguments from --set into a map
+// of template variables.
+func parseSetFlags(sets []string) map[string]string {
+	vars := make(map[string]string, len(sets))
+
+	for _, set := range sets {
+		key, val, ok := strings.Cut(set, "=")
+
+		if !ok {
+			errors.Throwf("invalid --set value %q, expected key=val", set)
+		}
+
+		vars[key] = val
+	}
+
+	return vars
+}
+
+// templateRepoNameRegexp matches characters not safe to use in a cache
+// directory name.
+var templateRepoNameRegexp = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// templateRepoDirName derives a stable cache directory name from a remote
+// repository URL.
+func templateRepoDirName(remote string) string {
+	return templateRepoNameRegexp.ReplaceAllString(remote, "_")
+}
+
+// defaultTemplateHome returns the default directory used to cache remote
+// templates when --home is not set.
+func defaultTemplateHome() string {
+	if cacheHome := os.Getenv("XDG_CACHE_HOME"); cacheHome != "" {
+		return path.Join(cacheHome, "shana", "templates")
+	}
+
+	home := errors.Check1(os.UserHomeDir())
+	return path.Join(home, ".shana", "templates")
+}
+
+// fetchRemoteTemplate clones the remote template repository into the
+// template home directory on first use, or fetches and checks out the
+// requested branch on subsequent uses. It returns the path to the root of
+// the checked-out template repository.
+func fetchRemoteTemplate(remote, branch, home string) (dir string) {
+	if home == "" {
+		home = defaultTemplateHome()
+	}
+
+	errors.Check(os.MkdirAll(home, 0755))
+
+	dir = path.Join(home, templateRepoDirName(remote))
+
+	if stats, statErr := os.Stat(dir); statErr == nil && stats.IsDir() {
+		fmt.Fprintf(os.Stderr, "Updating cached template %v.\n", remote)
+
+		fetch := exec.Command("git", "fetch", "origin", branch)
+		fetch.Dir = dir
+		fetch.Stdout = os.Stderr
+		fetch.Stderr = os.Stderr
+		errors.Check(fetch.Run())
+
+		reset := exec.Command("git", "reset", "--hard", "origin/"+branch)
+		reset.Dir = dir
+		reset.Stdout = os.Stderr
+		reset.Stderr = os.Stderr
+		errors.Check(reset.Run())
+
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "Cloning template %v.\n", remote)
+
+	clone := exec.Command("git", "clone", "--branch", branch, remote, dir)
+	clone.Stdout = os.Stderr
+	clone.Stderr = os.Stderr
+	errors.Check(clone.Run())
+
+	return
+}
+
+// loadTemplateManifest reads the optional shana.tmpl.yaml manifest from a
+// template directory. It's not an error for the manifest to be missing.
+func loadTemplateManifest(templateDir string) (manifest *templateManifest) {
+	manifest = &templateManifest{}
+	manifestFile := path.Join(templateDir, templateManifestName)
+
+	if !isFileExists(manifestFile) {
+		return
+	}
+
+	data := errors.Check1(os.ReadFile(manifestFile))
+	errors.Check(yaml.Unmarshal(data, manifest))
+	return
+}
+
+// templateFile is a file to generate into a new project: either a template
+// to execute, for files that parse as one, or the raw bytes to copy
+// verbatim, for files that don't (binary assets, or text that merely
+// contains "{{"/"}}" that isn't a valid template action).
+type templateFile struct {
+	name string
+	tmpl *template.Template
+	raw  []byte
+}
+
+// loadTemplatesFromDir walks a template directory and loads every file,
+// keyed by its path relative to the directory root. Files that parse as a
+// text/template are executed against the create context; files that don't
+// are copied verbatim, so binary assets in a remote template repository
+// don't crash 'shana create --remote'.
+func loadTemplatesFromDir(dir string) (files []templateFile) {
+	errors.Check(filepath.WalkDir(dir, func(p string, d fs.DirEntry, walkErr error) error {
+		errors.Check(walkErr)
+
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		rel := errors.Check1(filepath.Rel(dir, p))
+
+		if rel == templateManifestName {
+			return nil
+		}
+
+		data := errors.Check1(os.ReadFile(p))
+
+		if tmpl, parseErr := template.New(rel).Parse(string(data)); parseErr == nil {
+			files = append(files, templateFile{name: rel, tmpl: tmpl})
+		} else {
+			files = append(files, templateFile{name: rel, raw: data})
+		}
+
+		return nil
+	}))
+
+	return
+}
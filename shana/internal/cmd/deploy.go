@@ -0,0 +1,318 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/go-shana/cmd/shana/internal/goinvoke"
+	"github.com/go-shana/core/errors"
+	"github.com/spf13/cobra"
+)
+
+// cmdDeployContext is the template data available to a DeployTarget's
+// templates.
+type cmdDeployContext struct {
+	Service      string
+	PkgName      string
+	ShanaCorePkg string
+
+	// BinaryName is the filename of the compiled binary inside the bundle.
+	BinaryName string
+}
+
+// DeployTarget describes a production deployment artifact 'shana deploy' can
+// emit into the bundle, e.g. a systemd unit or a Dockerfile.
+type DeployTarget struct {
+	// Name is the value passed to --target, e.g. "systemd".
+	Name string
+
+	// Description is shown in the command's help text.
+	Description string
+
+	// Templates returns the files to generate into the bundle for ctx.
+	Templates func(ctx *cmdDeployContext) []*template.Template
+
+	// Executables lists template names (as returned by Templates) that must
+	// be written with the executable bit set, e.g. runit's "run" scripts.
+	Executables []string
+}
+
+var (
+	deployTargets     = map[string]*DeployTarget{}
+	deployTargetOrder []string
+)
+
+// RegisterDeployTarget registers a deployment target for use with
+// 'shana deploy'. It's meant to be called from an init function.
+func RegisterDeployTarget(t DeployTarget) {
+	if _, ok := deployTargets[t.Name]; ok {
+		panic("cmd: deploy target '" + t.Name + "' is already registered")
+	}
+
+	target := t
+	deployTargets[t.Name] = &target
+	deployTargetOrder = append(deployTargetOrder, t.Name)
+}
+
+func init() {
+	RegisterDeployTarget(DeployTarget{
+		Name:        "runit",
+		Description: "A runit 'sv' directory (run, log/run, finish) pointing at the binary.",
+		Templates:   runitTemplates,
+		Executables: []string{"run", "log/run", "finish"},
+	})
+
+	RegisterDeployTarget(DeployTarget{
+		Name:        "systemd",
+		Description: "A systemd unit file with ExecStart, Restart=on-failure and an EnvironmentFile.",
+		Templates:   systemdTemplates,
+	})
+
+	RegisterDeployTarget(DeployTarget{
+		Name:        "docker",
+		Description: "A minimal distroless Dockerfile plus .dockerignore.",
+		Templates:   dockerTemplates,
+	})
+}
+
+var runitRunTemplate = template.Must(template.New("run").Parse(`#!/bin/sh
+exec 2>&1
+exec ./{{.BinaryName}}
+`))
+
+var runitLogRunTemplate = template.Must(template.New("log/run").Parse(`#!/bin/sh
+exec svlogd -tt .
+`))
+
+var runitFinishTemplate = template.Must(template.New("finish").Parse(`#!/bin/sh
+exit 0
+`))
+
+func runitTemplates(ctx *cmdDeployContext) []*template.Template {
+	return []*template.Template{
+		runitRunTemplate,
+		runitLogRunTemplate,
+		runitFinishTemplate,
+	}
+}
+
+const systemdUnitTemplateSource = `[Unit]
+Description={{.Service}}
+After=network.target
+
+[Service]
+Type=simple
+WorkingDirectory=/opt/{{.Service}}
+ExecStart=/opt/{{.Service}}/{{.BinaryName}}
+EnvironmentFile=/opt/{{.Service}}/shana.yaml
+Restart=on-failure
+
+[Install]
+WantedBy=multi-user.target
+`
+
+// systemdTemplates builds a {{.Service}}.service unit, whose filename
+// depends on ctx so it can't be a package-level var like the other targets.
+func systemdTemplates(ctx *cmdDeployContext) []*template.Template {
+	unitName := ctx.Service + ".service"
+	return []*template.Template{
+		template.Must(template.New(unitName).Parse(systemdUnitTemplateSource)),
+	}
+}
+
+var dockerfileTemplate = template.Must(template.New("Dockerfile").Parse(`FROM gcr.io/distroless/static-debian12
+
+WORKDIR /app
+COPY {{.BinaryName}} /app/{{.BinaryName}}
+COPY shana.yaml /app/shana.yaml
+
+ENTRYPOINT ["/app/{{.BinaryName}}"]
+`))
+
+var dockerignoreTemplate = template.Must(template.New(".dockerignore").Parse(`dist/
+*.log
+`))
+
+func dockerTemplates(ctx *cmdDeployContext) []*template.Template {
+	return []*template.Template{
+		dockerfileTemplate,
+		dockerignoreTemplate,
+	}
+}
+
+// describeDeployTargets renders the registered deploy targets as a bullet
+// list, in registration order, for use in help text.
+func describeDeployTargets() string {
+	var b strings.Builder
+
+	for _, name := range deployTargetOrder {
+		target := deployTargets[name]
+		fmt.Fprintf(&b, "  - %s: %s\n", target.Name, target.Description)
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func init() {
+	rootCmd.AddCommand(deployCmd)
+
+	deployCmd.Flags().StringArray("target", nil, "Deployment artifact to emit, can be repeated: runit, systemd, docker")
+	deployCmd.Flags().String("os", "", "GOOS to cross-compile for (defaults to the host's)")
+	deployCmd.Flags().String("arch", "", "GOARCH to cross-compile for (defaults to the host's)")
+	deployCmd.Flags().String("ldflags", "", "Extra -ldflags to pass to 'go build'")
+
+	deployCmd.Long += "\n\nSupported deploy targets:\n\n" + describeDeployTargets()
+}
+
+// deployCmd represents the deploy command
+var deployCmd = &cobra.Command{
+	Use:   "deploy server-proto --target <target> [flags] -- [go build flags]",
+	Short: "Build a production deployment bundle for current microservice",
+	Long: `The 'shana deploy' command cross-compiles current microservice into a
+production binary and writes a deployment bundle under './dist/<service>/'.
+
+The 'server-proto' argument is the same as 'shana run's; see
+'shana run --list-protos' for the list of supported protocols.
+
+Flags after '--' will be passed to 'go build' command to build the service.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		defer errors.Handle(&err)
+
+		ctx, cancel := commandContext(cmd)
+		defer cancel()
+
+		targetNames := errors.Check1(cmd.Flags().GetStringArray("target"))
+
+		if len(targetNames) == 0 {
+			errors.Throwf("at least one --target is required, e.g. --target systemd")
+			return
+		}
+
+		targets := make([]*DeployTarget, 0, len(targetNames))
+
+		for _, name := range targetNames {
+			target, ok := deployTargets[name]
+
+			if !ok {
+				errors.Throwf("unsupported deploy target '%v'", name)
+				return
+			}
+
+			targets = append(targets, target)
+		}
+
+		goos := errors.Check1(cmd.Flags().GetString("os"))
+		goarch := errors.Check1(cmd.Flags().GetString("arch"))
+		ldflags := errors.Check1(cmd.Flags().GetString("ldflags"))
+
+		serverType := args[0]
+		proto, ok := serverProtos[serverType]
+
+		if !ok {
+			errors.Throwf("unsupported server-proto '%v', see 'shana run --list-protos'", serverType)
+			return
+		}
+
+		projectRoot, pkgName, modFile, workFile, pkgs := prepareService(ctx, proto)
+
+		// Generate the production main.go/go.mod/go.work into a throwaway
+		// build directory, the same way 'shana run' does for its workspace.
+		buildDir := errors.Check1(os.MkdirTemp("", "shana-deploy-*"))
+		defer os.RemoveAll(buildDir)
+
+		runCtx := &cmdRunContext{
+			PkgName:      pkgName,
+			ProjectRoot:  projectRoot,
+			CacheDir:     buildDir,
+			ShanaCorePkg: shanaCorePackage,
+			ServicePkgs:  pkgs,
+			ModFile:      modFile,
+			WorkFile:     workFile,
+		}
+
+		for _, tmpl := range listRunTemplates(proto, runCtx) {
+			createFile(path.Join(buildDir, tmpl.Name()), tmpl, runCtx)
+		}
+
+		tidy := goinvoke.Invocation{Verb: "mod", Args: []string{"tidy"}, WorkingDir: buildDir}
+		errors.Check(tidy.Run(ctx))
+
+		service := normalizeProjectName(path.Base(pkgName))
+		bundleDir := errors.Check1(filepath.Abs(path.Join("dist", service)))
+		errors.Check(os.MkdirAll(bundleDir, 0755))
+
+		binaryName := service
+		buildArgs := []string{"-o", path.Join(bundleDir, binaryName)}
+
+		if ldflags != "" {
+			buildArgs = append(buildArgs, "-ldflags", ldflags)
+		}
+
+		buildArgs = append(buildArgs, parseGoBuildFlags(args)...)
+
+		build := goinvoke.Invocation{
+			Verb:       "build",
+			Args:       buildArgs,
+			WorkingDir: buildDir,
+		}
+
+		if goos != "" {
+			build.Env = append(build.Env, "GOOS="+goos)
+		}
+
+		if goarch != "" {
+			build.Env = append(build.Env, "GOARCH="+goarch)
+		}
+
+		errors.Check(build.Run(ctx))
+
+		// Embed shana.yaml next to the binary, the way 'shana run' links it
+		// into its cache dir. bundleDir is reused across deploys (unlike
+		// run's throwaway cache dir), so drop any stale link first.
+		configFile := path.Join(projectRoot, shanaYAML)
+
+		if isFileExists(configFile) {
+			bundledConfigFile := path.Join(bundleDir, shanaYAML)
+
+			if err := os.Remove(bundledConfigFile); err != nil && !os.IsNotExist(err) {
+				errors.Check(err)
+			}
+
+			errors.Check(os.Link(configFile, bundledConfigFile))
+		}
+
+		deployCtx := &cmdDeployContext{
+			Service:      service,
+			PkgName:      pkgName,
+			ShanaCorePkg: shanaCorePackage,
+			BinaryName:   binaryName,
+		}
+
+		for _, target := range targets {
+			executable := make(map[string]bool, len(target.Executables))
+
+			for _, name := range target.Executables {
+				executable[name] = true
+			}
+
+			for _, tmpl := range target.Templates(deployCtx) {
+				filename := path.Join(bundleDir, tmpl.Name())
+				errors.Check(os.MkdirAll(path.Dir(filename), 0755))
+				createFile(filename, tmpl, deployCtx)
+
+				if executable[tmpl.Name()] {
+					errors.Check(os.Chmod(filename, 0755))
+				}
+			}
+		}
+
+		fmt.Fprintf(os.Stderr, "Deployment bundle written to %v.\n", bundleDir)
+
+		return
+	},
+}
@@ -1,7 +1,9 @@
 package cmd
 
 import (
+	"context"
 	"os"
+	"os/signal"
 	"text/template"
 
 	"github.com/go-shana/core/errors"
@@ -23,12 +25,35 @@ var rootCmd = &cobra.Command{
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	err := rootCmd.Execute()
+	// Cancel every command's context on Ctrl+C, so goinvoke.Invocation calls
+	// made through cmd.Context() tear down their subprocess uniformly.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	err := rootCmd.ExecuteContext(ctx)
 	if err != nil {
 		os.Exit(1)
 	}
 }
 
+func init() {
+	rootCmd.PersistentFlags().Duration("timeout", 0, "Maximum duration to run the command before it's cancelled (0 means no timeout)")
+}
+
+// commandContext returns cmd's context, narrowed by --timeout if it's set
+// to a positive duration. The returned cancel must be deferred by the
+// caller even when no timeout applies.
+func commandContext(cmd *cobra.Command) (ctx context.Context, cancel context.CancelFunc) {
+	ctx = cmd.Context()
+	timeout := errors.Check1(cmd.Flags().GetDuration("timeout"))
+
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
+
 func createFile(filename string, tmpl *template.Template, data any) {
 	f := errors.Check1(os.Create(filename))
 	defer f.Close()
@@ -1,35 +1,127 @@
 package cmd
 
 import (
-	"bytes"
+	"context"
 	"fmt"
+	"io/fs"
 	"os"
 	"os/exec"
 	"os/signal"
 	"path"
+	"path/filepath"
 	"sort"
 	"strings"
 	"sync/atomic"
 	"syscall"
 	"text/template"
+	"time"
 
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-shana/cmd/shana/internal/goinvoke"
 	"github.com/go-shana/core/errors"
 	"github.com/spf13/cobra"
 	"golang.org/x/mod/modfile"
 	"golang.org/x/mod/module"
+	"gopkg.in/yaml.v3"
 )
 
 const shanaBuildServiceBinaryName = "shana-build-service"
 
+// serviceTerminateGracePeriod is how long stopService waits after sending
+// SIGTERM to the running service before escalating to SIGKILL, mirroring
+// goinvoke's terminateGracePeriod.
+const serviceTerminateGracePeriod = 5 * time.Second
+
+// defaultWatchSkipDirs are directories that are never watched, regardless
+// of --watch-exclude.
+var defaultWatchSkipDirs = []string{"vendor", ".git", "testdata"}
+
 type cmdRunContext struct {
 	PkgName      string
 	ProjectRoot  string
+	CacheDir     string
 	ShanaCorePkg string
 	ServicePkgs  []string
 	ModFile      *modfile.File
 	WorkFile     *modfile.WorkFile
 	UseLocalCore bool
+
+	// ProtoImports are extra package paths a ServerProto's main.go template
+	// should blank-import, e.g. generated gRPC code. Populated by
+	// ServerProto.Templates.
+	ProtoImports []string
+}
+
+// ServerProto describes a server protocol that 'shana run' can scaffold and
+// launch a service for.
+type ServerProto struct {
+	// Name is the value passed as the 'server-proto' argument, e.g. "httpjson".
+	Name string
+
+	// Description is shown in 'shana run --list-protos' and the command's help text.
+	Description string
+
+	// Templates returns the proto-specific template files (typically at
+	// least a main.go) to generate into the run workspace. It may enrich ctx
+	// (e.g. ctx.ProtoImports) before the templates are executed.
+	Templates func(ctx *cmdRunContext) []*template.Template
+
+	// ExtraRequires lists additional module requirements this proto needs in
+	// the synthesized go.mod, beyond github.com/go-shana/core.
+	ExtraRequires []module.Version
+}
+
+var (
+	serverProtos     = map[string]*ServerProto{}
+	serverProtoOrder []string
+)
+
+// RegisterServerProto registers a server protocol for use with 'shana run'.
+// It's meant to be called from an init function.
+func RegisterServerProto(p ServerProto) {
+	if _, ok := serverProtos[p.Name]; ok {
+		panic("cmd: server-proto '" + p.Name + "' is already registered")
+	}
+
+	proto := p
+	serverProtos[p.Name] = &proto
+	serverProtoOrder = append(serverProtoOrder, p.Name)
+}
+
+// Note: a "grpc" server-proto isn't registered here because
+// github.com/go-shana/core doesn't ship an rpc/grpc package yet. Register
+// one via RegisterServerProto once core provides it.
+func init() {
+	RegisterServerProto(ServerProto{
+		Name:        "httpjson",
+		Description: "Shana-opinioned HTTP JSON server.",
+		Templates: func(ctx *cmdRunContext) []*template.Template {
+			return []*template.Template{httpjsonMainTemplate}
+		},
+	})
+}
+
+var httpjsonMainTemplate = template.Must(template.New("main.go").Parse(`package main
+
+import (
+	"{{.ShanaCorePkg}}/config"
+	"{{.ShanaCorePkg}}/launcher"
+	"{{.ShanaCorePkg}}/rpc"
+	"{{.ShanaCorePkg}}/rpc/httpjson"
+
+{{range .ProtoImports}}	_ "{{.}}"{{println}}{{end -}}
+{{range .ServicePkgs}}	_ "{{.}}"{{println}}{{end -}}
+)
+
+var serverConfig = config.New[httpjson.Config]("shana.httpjson")
+
+func main() {
+	launcher.Launch(func() rpc.Server {
+		serverConfig.PkgPrefix = "{{.PkgName}}"
+		return httpjson.NewServer(serverConfig)
+	})
 }
+`))
 
 // runCmd represents the run command
 var runCmd = &cobra.Command{
@@ -39,37 +131,69 @@ var runCmd = &cobra.Command{
 It's designed to be a development tool, not for production.
 
 The 'server-proto' specifies the server protocol used by the service.
-Here is a list of supported server protocols:
+More server protocols can be added via cmd.RegisterServerProto.
+
+Flags after '--' will be passed to 'go build' command to build the service.
 
-  - httpjson: Shana-opinioned HTTP JSON server.
+Use '--watch' to rebuild and restart the service automatically whenever
+a watched file changes, similar to 'air'/'reflex'.
 
-More server protocols will be supported in the future.
+Use '--list-protos' to print all registered server protocols and exit.
 
-Flags after '--' will be passed to 'go build' command to build the service.`,
-	Args: cobra.MinimumNArgs(1),
+Use '--set key.path=value' (repeatable) and SHANA_<UPPER_SNAKE> environment
+variables to override shana.yaml for this run, without editing the file.
+Overrides apply with precedence --set > env > shana.yaml. Use '--config' to
+point at an alternate file, and '--print-config' to see the resolved
+document without building.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if listProtos := errors.Check1(cmd.Flags().GetBool("list-protos")); listProtos {
+			return nil
+		}
+
+		return cobra.MinimumNArgs(1)(cmd, args)
+	},
 	RunE: func(cmd *cobra.Command, args []string) (err error) {
 		defer errors.Handle(&err)
 
-		projectRoot, pkgName, modFile, workFile := findGoModule()
-		errors.Assert(projectRoot != "", pkgName != "")
+		if listProtos := errors.Check1(cmd.Flags().GetBool("list-protos")); listProtos {
+			printServerProtos()
+			return
+		}
+
+		ctx, cancel := commandContext(cmd)
+		defer cancel()
+
+		watch := errors.Check1(cmd.Flags().GetBool("watch"))
+		watchExt := errors.Check1(cmd.Flags().GetStringSlice("watch-ext"))
+		watchExclude := errors.Check1(cmd.Flags().GetStringArray("watch-exclude"))
+		debounce := errors.Check1(cmd.Flags().GetDuration("debounce"))
+		configPath := errors.Check1(cmd.Flags().GetString("config"))
+		configSets := errors.Check1(cmd.Flags().GetStringArray("set"))
+		printConfig := errors.Check1(cmd.Flags().GetBool("print-config"))
+
+		serverType := args[0]
+		proto, ok := serverProtos[serverType]
+
+		if !ok {
+			errors.Throwf("unsupported server-proto '%v', see 'shana run --list-protos'", serverType)
+			return
+		}
 
-		// List all possible sub packages and sort them by name.
-		pkgs := listAllSubPackages(projectRoot)
+		projectRoot, pkgName, modFile, workFile, pkgs := prepareService(ctx, proto)
 
-		for i := range pkgs {
-			pkgs[i] = strings.Replace(pkgs[i], projectRoot, pkgName, 1)
+		if configPath == "" {
+			configPath = path.Join(projectRoot, shanaYAML)
 		}
 
-		sort.Strings(pkgs)
+		config := resolveConfig(configPath, configSets)
 
-		if len(pkgs) == 0 {
-			errors.Throwf("Fail to find any Go package in current project.")
+		if printConfig {
+			out := errors.Check1(yaml.Marshal(config))
+			os.Stdout.Write(out)
 			return
 		}
 
 		// Crate a temp directory and generate files to run the service.
-		serverType := args[0]
-		tmpls := listRunTemplates(serverType)
 		cacheDir := errors.Check1(os.MkdirTemp("", "shana-workspace-*"))
 
 		// Make sure cache dir is removed when SIGINT is signaled.
@@ -79,6 +203,12 @@ Flags after '--' will be passed to 'go build' command to build the service.`,
 		defer close(signalChan)
 		defer signal.Stop(signalChan)
 
+		// watchCtx is derived from the --timeout-bound ctx, and is also
+		// cancelled on Ctrl+C, so the --watch goroutine tears down cleanly
+		// in either case.
+		watchCtx, cancelWatch := context.WithCancel(ctx)
+		defer cancelWatch()
+
 		// Store command in an atomic pointer and share with the signal handler.
 		var cmdPtr atomic.Pointer[exec.Cmd]
 		var interrupted atomic.Bool
@@ -98,71 +228,125 @@ Flags after '--' will be passed to 'go build' command to build the service.`,
 			return
 		}
 
-		// Handle SIGINT.
-		go func() {
-			for range signalChan {
+		// Handle SIGINT and --timeout expiring: either stops the launched
+		// service the same way.
+		teardown := func(msg string) {
+			if interrupted.Load() {
+				return
+			}
 
-				if interrupted.Load() {
-					return
-				}
+			interrupted.Store(true)
+			cancelWatch()
 
-				interrupted.Store(true)
+			if ptr := cmdPtr.Load(); ptr != nil {
+				ptr.Process.Signal(syscall.SIGTERM)
+			}
 
-				if ptr := cmdPtr.Load(); ptr != nil {
-					ptr.Process.Signal(syscall.SIGTERM)
-				}
+			fmt.Fprintln(os.Stderr, msg)
+		}
 
-				fmt.Fprintln(os.Stderr, "Caught SIGINT")
+		go func() {
+			for range signalChan {
+				teardown("Caught SIGINT")
+			}
+		}()
+
+		go func() {
+			<-ctx.Done()
+
+			// Ctrl+C also cancels ctx; that case is reported by the SIGINT
+			// handler above.
+			if ctx.Err() == context.DeadlineExceeded {
+				teardown("Timed out")
 			}
 		}()
 
-		// Copy config file if exists.
-		configFile := path.Join(projectRoot, shanaYAML)
+		// Write the resolved config (file < env < --set) into the workspace.
+		writeResolvedConfig(config, cacheDir)
+
+		// reloadConfig re-resolves configPath and rewrites cacheDir/shana.yaml,
+		// for use whenever shana.yaml changes under --watch.
+		reloadConfig := func() (err error) {
+			defer errors.Handle(&err)
 
-		if isFileExists(configFile) {
-			errors.Check(os.Link(configFile, path.Join(cacheDir, shanaYAML)))
+			config = resolveConfig(configPath, configSets)
+			writeResolvedConfig(config, cacheDir)
+			return nil
 		}
 
 		// Generate template files.
 		cmdContext := &cmdRunContext{
 			PkgName:      pkgName,
 			ProjectRoot:  projectRoot,
+			CacheDir:     cacheDir,
 			ShanaCorePkg: shanaCorePackage,
 			ServicePkgs:  pkgs,
 			ModFile:      modFile,
 			WorkFile:     workFile,
 		}
 
+		tmpls := listRunTemplates(proto, cmdContext)
+
 		for _, tmpl := range tmpls {
 			createFile(path.Join(cacheDir, tmpl.Name()), tmpl, cmdContext)
 		}
 
-		// Tidy the go.mod file.
-		errors.Check(runCommand("Fail to tidy the go.mod file.", "go", "mod", "tidy"))
-
-		// Build the service.
-		goBuildArgs := []string{"build", "-o", shanaBuildServiceBinaryName}
+		// Build the service: tidy the go.mod file, then build the binary.
+		goBuildArgs := []string{"-o", shanaBuildServiceBinaryName}
 		goBuildArgs = append(goBuildArgs, parseGoBuildFlags(args)...)
-		errors.Check(runCommand("Fail to build the service.", "go", goBuildArgs...))
 
-		// TODO: use log to replace fmt.
-		fmt.Fprintln(os.Stderr, "Service is about to be launched. Press Ctrl+C to stop the service.")
+		build := func() error {
+			tidy := goinvoke.Invocation{Verb: "mod", Args: []string{"tidy"}, WorkingDir: cacheDir}
+
+			if e := tidy.Run(ctx); e != nil {
+				fmt.Fprintln(os.Stderr, "Fail to tidy the go.mod file.")
+				return e
+			}
+
+			buildInvocation := goinvoke.Invocation{Verb: "build", Args: goBuildArgs, WorkingDir: cacheDir}
+
+			if e := buildInvocation.Run(ctx); e != nil {
+				fmt.Fprintln(os.Stderr, "Fail to build the service.")
+				return e
+			}
+
+			return nil
+		}
+
+		errors.Check(build())
+
+		if !watch {
+			// TODO: use log to replace fmt.
+			fmt.Fprintln(os.Stderr, "Service is about to be launched. Press Ctrl+C to stop the service.")
+
+			// Run the service.
+			// Error is ignored because the service may be stopped by Ctrl+C.
+			runCommand("Service is stopped.", "./"+shanaBuildServiceBinaryName)
 
-		// Run the service.
-		// Error is ignored because the service may be stopped by Ctrl+C.
-		runCommand("Service is stopped.", "./"+shanaBuildServiceBinaryName)
+			return
+		}
+
+		errors.Check(watchAndRun(watchCtx, watchOptions{
+			ProjectRoot:  projectRoot,
+			CacheDir:     cacheDir,
+			Exts:         watchExt,
+			Excludes:     watchExclude,
+			Debounce:     debounce,
+			Build:        build,
+			ReloadConfig: reloadConfig,
+			Interrupted:  &interrupted,
+			RunningCmd:   &cmdPtr,
+		}))
 
 		return
 	},
 }
 
-func findGoModule() (projectRoot, pkgName string, modFile *modfile.File, workFile *modfile.WorkFile) {
-	command := exec.Command("go", "env", "GOMOD")
-	output := &bytes.Buffer{}
-	command.Stdout = output
-	errors.If(command.Run()).Throw(errors.New("Fail to find go.mod in current project."))
+func findGoModule(ctx context.Context) (projectRoot, pkgName string, modFile *modfile.File, workFile *modfile.WorkFile) {
+	invocation := goinvoke.Invocation{Verb: "env", Args: []string{"GOMOD"}}
+	output := errors.Check1(invocation.RunStdout(ctx))
 
-	goMod := strings.TrimSpace(output.String())
+	goMod := strings.TrimSpace(string(output))
 
 	if goMod == os.DevNull {
 		errors.Throwf("fail to find go.mod in current project.")
@@ -277,6 +461,33 @@ func listAllSubPackages(projectRoot string) (pkgs []string) {
 	return
 }
 
+// prepareService resolves the current project's go.mod/go.work, injects
+// proto's extra module requirements, and lists the project's importable sub
+// packages as import paths, sorted by name. It's shared by 'shana run' and
+// 'shana deploy', which both need to scaffold the same production workspace.
+func prepareService(ctx context.Context, proto *ServerProto) (projectRoot, pkgName string, modFile *modfile.File, workFile *modfile.WorkFile, pkgs []string) {
+	projectRoot, pkgName, modFile, workFile = findGoModule(ctx)
+	errors.Assert(projectRoot != "", pkgName != "")
+
+	for _, req := range proto.ExtraRequires {
+		modFile.Require = append(modFile.Require, &modfile.Require{Mod: req})
+	}
+
+	pkgs = listAllSubPackages(projectRoot)
+
+	for i := range pkgs {
+		pkgs[i] = strings.Replace(pkgs[i], projectRoot, pkgName, 1)
+	}
+
+	sort.Strings(pkgs)
+
+	if len(pkgs) == 0 {
+		errors.Throwf("Fail to find any Go package in current project.")
+	}
+
+	return
+}
+
 func parseGoBuildFlags(args []string) []string {
 	buildFlags := args
 	idx := 0
@@ -312,32 +523,156 @@ func parseGoBuildFlags(args []string) []string {
 
 func init() {
 	rootCmd.AddCommand(runCmd)
+
+	runCmd.Flags().Bool("watch", false, "Watch the project and rebuild/restart the service automatically on changes")
+	runCmd.Flags().StringSlice("watch-ext", []string{".go", "shana.yaml"}, "File extensions or exact file names that trigger a rebuild when changed")
+	runCmd.Flags().StringArray("watch-exclude", nil, "Glob patterns of paths to exclude from watching, can be repeated")
+	runCmd.Flags().Duration("debounce", 300*time.Millisecond, "Debounce window to coalesce rapid file changes before rebuilding")
+	runCmd.Flags().Bool("list-protos", false, "List all registered server protocols and exit")
+	runCmd.Flags().String("config", "", "Path to shana.yaml to use (defaults to shana.yaml in the project root)")
+	runCmd.Flags().StringArray("set", nil, "Override a shana.yaml value in the form key.path=value, can be repeated")
+	runCmd.Flags().Bool("print-config", false, "Print the resolved shana.yaml to stdout and exit without building")
+
+	runCmd.Long += "\n\nSupported server protocols:\n\n" + describeServerProtos()
 }
 
-func listRunTemplates(serverType string) []*template.Template {
-	var (
-		httpjsonMainTemplate = template.Must(template.New("main.go").Parse(`package main
+// configEnvPrefix is the prefix shana looks for when overlaying environment
+// variables onto shana.yaml, e.g. SHANA_SERVICE_WELCOME for service.welcome.
+const configEnvPrefix = "SHANA_"
 
-import (
-	"{{.ShanaCorePkg}}/config"
-	"{{.ShanaCorePkg}}/launcher"
-	"{{.ShanaCorePkg}}/rpc"
-	"{{.ShanaCorePkg}}/rpc/httpjson"
+// resolveConfig loads configPath (if it exists) as a YAML document and
+// overlays environment variables and --set overrides on top of it, with
+// precedence sets > env > file. It uses the yaml.v3 node API so comments
+// and the ordering of untouched keys in configPath survive the round trip.
+func resolveConfig(configPath string, sets []string) *yaml.Node {
+	doc := &yaml.Node{}
 
-{{range .ServicePkgs}}	_ "{{.}}"{{println}}{{end -}}
-)
+	if isFileExists(configPath) {
+		data := errors.Check1(os.ReadFile(configPath))
+		errors.Check(yaml.Unmarshal(data, doc))
+	}
 
-var serverConfig = config.New[httpjson.Config]("shana.httpjson")
+	if doc.Kind == 0 {
+		doc.Kind = yaml.DocumentNode
+		doc.Content = []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}
+	}
 
-func main() {
-	launcher.Launch(func() rpc.Server {
-		serverConfig.PkgPrefix = "{{.PkgName}}"
-		return httpjson.NewServer(serverConfig)
-	})
+	for dottedPath, value := range configEnvOverrides() {
+		setConfigValue(doc, dottedPath, value)
+	}
+
+	for _, set := range sets {
+		dottedPath, value, ok := strings.Cut(set, "=")
+
+		if !ok {
+			errors.Throwf("invalid --set value %q, expected key.path=value", set)
+		}
+
+		setConfigValue(doc, dottedPath, value)
+	}
+
+	return doc
 }
-`))
 
-		goModTemplate = template.Must(template.New("go.mod").Parse(`module {{.ModFile.Module.Mod.Path}}
+// writeResolvedConfig marshals config and writes it as cacheDir/shana.yaml.
+func writeResolvedConfig(config *yaml.Node, cacheDir string) {
+	out := errors.Check1(yaml.Marshal(config))
+	errors.Check(os.WriteFile(path.Join(cacheDir, shanaYAML), out, 0644))
+}
+
+// configEnvOverrides scans the environment for SHANA_-prefixed variables
+// and returns them keyed by the dotted config path they override, e.g.
+// SHANA_SERVICE_WELCOME=Hi becomes {"service.welcome": "Hi"}.
+func configEnvOverrides() map[string]string {
+	overrides := map[string]string{}
+
+	for _, env := range os.Environ() {
+		key, value, ok := strings.Cut(env, "=")
+
+		if !ok || !strings.HasPrefix(key, configEnvPrefix) {
+			continue
+		}
+
+		dotted := strings.ToLower(strings.ReplaceAll(strings.TrimPrefix(key, configEnvPrefix), "_", "."))
+		overrides[dotted] = value
+	}
+
+	return overrides
+}
+
+// setConfigValue sets the scalar value at dottedPath (e.g. "shana.debug")
+// in doc, creating any missing intermediate mappings and preserving
+// existing nodes (and their comments) along the way.
+func setConfigValue(doc *yaml.Node, dottedPath, value string) {
+	root := doc.Content[0]
+	keys := strings.Split(dottedPath, ".")
+
+	for _, key := range keys[:len(keys)-1] {
+		root = mappingChild(root, key)
+	}
+
+	setMappingValue(root, keys[len(keys)-1], value)
+}
+
+// mappingChild returns root's mapping value under key, creating an empty
+// mapping there if it's missing or isn't itself a mapping.
+func mappingChild(root *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == key {
+			if root.Content[i+1].Kind != yaml.MappingNode {
+				root.Content[i+1] = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			}
+
+			return root.Content[i+1]
+		}
+	}
+
+	keyNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}
+	valueNode := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	root.Content = append(root.Content, keyNode, valueNode)
+	return valueNode
+}
+
+// setMappingValue sets key to a scalar value in root's mapping, reusing the
+// existing key node (and its comments) when the key is already present.
+func setMappingValue(root *yaml.Node, key, value string) {
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == key {
+			root.Content[i+1] = &yaml.Node{Kind: yaml.ScalarNode, Value: value}
+			return
+		}
+	}
+
+	keyNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}
+	valueNode := &yaml.Node{Kind: yaml.ScalarNode, Value: value}
+	root.Content = append(root.Content, keyNode, valueNode)
+}
+
+// describeServerProtos renders the registered server protocols as a
+// bullet list, in registration order, for use in the command's help text.
+// 'shana run --list-protos' prints them with printServerProtos instead.
+func describeServerProtos() string {
+	var b strings.Builder
+
+	for _, name := range serverProtoOrder {
+		proto := serverProtos[name]
+		fmt.Fprintf(&b, "  - %s: %s\n", proto.Name, proto.Description)
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// printServerProtos prints the registered server protocols to stdout for
+// 'shana run --list-protos'.
+func printServerProtos() {
+	for _, name := range serverProtoOrder {
+		proto := serverProtos[name]
+		fmt.Fprintf(os.Stdout, "%-10s %s\n", proto.Name, proto.Description)
+	}
+}
+
+var (
+	goModRunTemplate = template.Must(template.New("go.mod").Parse(`module {{.ModFile.Module.Mod.Path}}
 
 go {{.ModFile.Go.Version}}
 
@@ -350,7 +685,7 @@ replace (
 )
 `))
 
-		goWorkTemplate = template.Must(template.New("go.work").Parse(`go {{.WorkFile.Go.Version}}
+	goWorkRunTemplate = template.Must(template.New("go.work").Parse(`go {{.WorkFile.Go.Version}}
 
 use (
 {{range .WorkFile.Use}}	{{.Path}}{{println}}{{end -}}
@@ -360,20 +695,231 @@ replace (
 {{range .WorkFile.Replace}}	{{.Old.Path}} {{- .Old.Version}} => {{.New.Path}} {{- .New.Version}}{{println}}{{end -}}
 )
 `))
-	)
+)
 
+// listRunTemplates returns the full set of template files to generate into
+// the run workspace for proto: the shared go.mod/go.work plus whatever
+// proto.Templates contributes.
+func listRunTemplates(proto *ServerProto, ctx *cmdRunContext) []*template.Template {
 	tmpls := []*template.Template{
-		goModTemplate,
-		goWorkTemplate,
+		goModRunTemplate,
+		goWorkRunTemplate,
+	}
+
+	return append(tmpls, proto.Templates(ctx)...)
+}
+
+// watchOptions configures watchAndRun.
+type watchOptions struct {
+	ProjectRoot string
+	CacheDir    string
+	Exts        []string
+	Excludes    []string
+	Debounce    time.Duration
+
+	// Build tidies and builds the service binary into CacheDir.
+	Build func() error
+
+	// ReloadConfig re-resolves shana.yaml (file < env < --set) and rewrites
+	// it into CacheDir. It's called whenever a change to shana.yaml is
+	// detected, before Build.
+	ReloadConfig func() error
+
+	Interrupted *atomic.Bool
+	RunningCmd  *atomic.Pointer[exec.Cmd]
+}
+
+// watchAndRun launches the service binary and watches the project root for
+// changes, rebuilding and relaunching the service whenever a watched file is
+// modified. It blocks until ctx is cancelled.
+func watchAndRun(ctx context.Context, opts watchOptions) (err error) {
+	defer errors.Handle(&err)
+
+	watcher := errors.Check1(fsnotify.NewWatcher())
+	defer watcher.Close()
+
+	errors.Check(addWatchDirs(watcher, opts.ProjectRoot, opts.Excludes))
+
+	// serviceDone carries the single Wait() call's result for the running
+	// service. It must be the only goroutine that calls command.Wait, since
+	// concurrent Wait calls on one *exec.Cmd race in os/exec.
+	var serviceDone atomic.Pointer[chan error]
+
+	startService := func() {
+		if opts.Interrupted.Load() {
+			return
+		}
+
+		command := exec.Command("./" + shanaBuildServiceBinaryName)
+		command.Dir = opts.CacheDir
+		command.Stdout = os.Stdout
+		command.Stderr = os.Stderr
+		errors.Check(command.Start())
+
+		done := make(chan error, 1)
+		go func() { done <- command.Wait() }()
+
+		serviceDone.Store(&done)
+		opts.RunningCmd.Store(command)
+	}
+
+	stopService := func() {
+		ptr := opts.RunningCmd.Load()
+
+		if ptr == nil {
+			return
+		}
+
+		done := *serviceDone.Load()
+		ptr.Process.Signal(syscall.SIGTERM)
+
+		select {
+		case <-done:
+		case <-time.After(serviceTerminateGracePeriod):
+			ptr.Process.Kill()
+			<-done
+		}
+
+		opts.RunningCmd.Store(nil)
 	}
 
-	switch serverType {
-	case "httpjson":
-		tmpls = append(tmpls, httpjsonMainTemplate)
+	fmt.Fprintln(os.Stderr, "Service is about to be launched. Watching for changes. Press Ctrl+C to stop.")
+	startService()
+	defer stopService()
+
+	// configChanged tracks whether shana.yaml was among the files that
+	// triggered the pending rebuild, so rebuild knows to re-resolve it.
+	var configChanged atomic.Bool
+
+	rebuild := func() {
+		fmt.Fprintln(os.Stderr, "Change detected, rebuilding the service.")
+		stopService()
 
-	default:
-		errors.Throwf("unsupported server-proto '%v'", serverType)
+		if configChanged.Swap(false) && opts.ReloadConfig != nil {
+			if e := opts.ReloadConfig(); e != nil {
+				fmt.Fprintln(os.Stderr, "Fail to reload shana.yaml:", e)
+				return
+			}
+		}
+
+		if e := opts.Build(); e != nil {
+			fmt.Fprintln(os.Stderr, "Fail to rebuild the service:", e)
+			return
+		}
+
+		startService()
+	}
+
+	var debounceTimer *time.Timer
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+
+			if !matchesWatchExt(event.Name, opts.Exts) {
+				continue
+			}
+
+			if isWatchExcluded(event.Name, opts.ProjectRoot, opts.Excludes) {
+				continue
+			}
+
+			if filepath.Base(event.Name) == shanaYAML {
+				configChanged.Store(true)
+			}
+
+			if debounceTimer != nil {
+				debounceTimer.Stop()
+			}
+
+			debounceTimer = time.AfterFunc(opts.Debounce, rebuild)
+
+		case watchErr, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+
+			fmt.Fprintln(os.Stderr, "Watch error:", watchErr)
+		}
+	}
+}
+
+// addWatchDirs recursively adds projectRoot and its subdirectories to
+// watcher, skipping vendored code, VCS metadata, test fixture directories,
+// and any directory matching an --watch-exclude glob.
+func addWatchDirs(watcher *fsnotify.Watcher, projectRoot string, excludes []string) (err error) {
+	defer errors.Handle(&err)
+
+	errors.Check(filepath.WalkDir(projectRoot, func(p string, d fs.DirEntry, walkErr error) error {
+		errors.Check(walkErr)
+
+		if !d.IsDir() {
+			return nil
+		}
+
+		name := d.Name()
+
+		for _, skip := range defaultWatchSkipDirs {
+			if name == skip {
+				return filepath.SkipDir
+			}
+		}
+
+		if isWatchExcluded(p, projectRoot, excludes) {
+			return filepath.SkipDir
+		}
+
+		errors.Check(watcher.Add(p))
+		return nil
+	}))
+
+	return
+}
+
+// matchesWatchExt reports whether the changed file's name matches one of
+// the configured watch extensions (e.g. ".go") or exact file names (e.g.
+// "shana.yaml").
+func matchesWatchExt(name string, exts []string) bool {
+	base := filepath.Base(name)
+
+	for _, ext := range exts {
+		if strings.HasPrefix(ext, ".") {
+			if strings.HasSuffix(base, ext) {
+				return true
+			}
+
+			continue
+		}
+
+		if base == ext {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isWatchExcluded reports whether p matches one of the --watch-exclude
+// globs, tested against both the path relative to projectRoot and the
+// file's base name.
+func isWatchExcluded(p, projectRoot string, excludes []string) bool {
+	rel := errors.Check1(filepath.Rel(projectRoot, p))
+
+	for _, pattern := range excludes {
+		if ok, _ := filepath.Match(pattern, rel); ok {
+			return true
+		}
+
+		if ok, _ := filepath.Match(pattern, filepath.Base(p)); ok {
+			return true
+		}
 	}
 
-	return tmpls
+	return false
 }
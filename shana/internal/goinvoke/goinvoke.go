@@ -0,0 +1,203 @@
+// Package goinvoke runs the 'go' tool as a subprocess, the way
+// golang.org/x/tools/internal/gocommand.Invocation does for gopls. It
+// centralizes the signal handling, error wrapping and stdout/stderr
+// plumbing that used to be duplicated across shana's cmd package.
+package goinvoke
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// terminateGracePeriod is how long Run waits after sending SIGTERM to a
+// subprocess whose context was cancelled before escalating to SIGKILL.
+const terminateGracePeriod = 5 * time.Second
+
+// Invocation is a single execution of the 'go' tool.
+type Invocation struct {
+	// Verb is the go subcommand, e.g. "build", "list", "mod".
+	Verb string
+
+	// Args are the arguments following Verb, e.g. []string{"tidy"} for
+	// "go mod tidy".
+	Args []string
+
+	// Env is appended to os.Environ() for the subprocess. Use it to set
+	// things like GOFLAGS or GOPROXY for a single invocation.
+	Env []string
+
+	// WorkingDir is the subprocess's working directory. Defaults to the
+	// current directory.
+	WorkingDir string
+
+	// Stdin, if set, is piped to the subprocess's stdin.
+	Stdin io.Reader
+
+	// BuildFlags are inserted between Verb and Args, e.g. []string{"-v"}.
+	BuildFlags []string
+}
+
+// args returns the full 'go' argument list for the invocation.
+func (i *Invocation) args() []string {
+	args := make([]string, 0, 1+len(i.BuildFlags)+len(i.Args))
+	args = append(args, i.Verb)
+	args = append(args, i.BuildFlags...)
+	args = append(args, i.Args...)
+	return args
+}
+
+// String renders the invocation as the command line a user would type,
+// for use in error messages.
+func (i *Invocation) String() string {
+	return "go " + strings.Join(i.args(), " ")
+}
+
+// Run runs the invocation, streaming its stdout/stderr to os.Stdout and
+// os.Stderr, using DefaultRunner.
+func (i *Invocation) Run(ctx context.Context) error {
+	return DefaultRunner.Run(ctx, i)
+}
+
+// RunStdout runs the invocation using DefaultRunner and returns its
+// captured stdout.
+func (i *Invocation) RunStdout(ctx context.Context) ([]byte, error) {
+	return DefaultRunner.RunStdout(ctx, i)
+}
+
+// RunRaw runs the invocation using DefaultRunner and returns its captured
+// stdout and stderr. If the subprocess fails, friendlyErr wraps err with
+// the command line and captured stderr for display to the user.
+func (i *Invocation) RunRaw(ctx context.Context) (stdout, stderr *bytes.Buffer, friendlyErr, err error) {
+	return DefaultRunner.RunRaw(ctx, i)
+}
+
+// serializedVerbs are go subcommands that mutate go.mod/go.sum or the
+// module cache and must not run concurrently with each other. They don't
+// take a concurrency slot, so read-only invocations may still run alongside
+// them.
+var serializedVerbs = map[string]bool{
+	"mod": true,
+}
+
+// Runner executes Invocations, serializing the ones that mutate module
+// state (e.g. 'go mod tidy') while letting read-only ones (e.g. 'go list',
+// 'go env') run in parallel, up to a concurrency limit.
+type Runner struct {
+	serializeMu sync.Mutex
+	concurrent  chan struct{}
+}
+
+// DefaultRunner is the Runner used by Invocation.Run, RunStdout and RunRaw.
+var DefaultRunner = NewRunner(8)
+
+// NewRunner creates a Runner that allows up to maxConcurrency read-only
+// invocations to run at once.
+func NewRunner(maxConcurrency int) *Runner {
+	if maxConcurrency < 1 {
+		maxConcurrency = 1
+	}
+
+	return &Runner{concurrent: make(chan struct{}, maxConcurrency)}
+}
+
+// acquire blocks until it's safe to start i, returning a func to release
+// the slot once the invocation has finished.
+func (runner *Runner) acquire(i *Invocation) (release func()) {
+	if serializedVerbs[i.Verb] {
+		runner.serializeMu.Lock()
+		return runner.serializeMu.Unlock
+	}
+
+	runner.concurrent <- struct{}{}
+	return func() { <-runner.concurrent }
+}
+
+// run starts i with stdout/stderr connected to the given writers, and
+// waits for it to finish or ctx to be done, whichever comes first.
+func (runner *Runner) run(ctx context.Context, i *Invocation, stdout, stderr io.Writer) (err error) {
+	release := runner.acquire(i)
+	defer release()
+
+	command := exec.Command("go", i.args()...)
+	command.Dir = i.WorkingDir
+	command.Stdin = i.Stdin
+	command.Stdout = stdout
+	command.Stderr = stderr
+
+	if len(i.Env) > 0 {
+		command.Env = append(os.Environ(), i.Env...)
+	}
+
+	if err = command.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- command.Wait() }()
+
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = terminate(command, done)
+	}
+
+	return
+}
+
+// terminate asks command to stop via SIGTERM, escalating to SIGKILL if it
+// hasn't exited after terminateGracePeriod.
+func terminate(command *exec.Cmd, done <-chan error) error {
+	command.Process.Signal(syscall.SIGTERM)
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(terminateGracePeriod):
+		command.Process.Kill()
+		return <-done
+	}
+}
+
+// Run runs i, streaming its stdout/stderr to os.Stdout/os.Stderr.
+func (runner *Runner) Run(ctx context.Context, i *Invocation) error {
+	if err := runner.run(ctx, i, os.Stdout, os.Stderr); err != nil {
+		return fmt.Errorf("%v: %w", i, err)
+	}
+
+	return nil
+}
+
+// RunStdout runs i and returns its captured stdout. Stderr is still
+// streamed to os.Stderr so build/tidy diagnostics remain visible.
+func (runner *Runner) RunStdout(ctx context.Context, i *Invocation) ([]byte, error) {
+	stdout := &bytes.Buffer{}
+
+	if err := runner.run(ctx, i, stdout, os.Stderr); err != nil {
+		return nil, fmt.Errorf("%v: %w", i, err)
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// RunRaw runs i, capturing both stdout and stderr instead of streaming
+// them. If the subprocess fails, friendlyErr wraps err with the command
+// line and captured stderr for display to the user.
+func (runner *Runner) RunRaw(ctx context.Context, i *Invocation) (stdout, stderr *bytes.Buffer, friendlyErr, err error) {
+	stdout = &bytes.Buffer{}
+	stderr = &bytes.Buffer{}
+	err = runner.run(ctx, i, stdout, stderr)
+
+	if err != nil {
+		friendlyErr = fmt.Errorf("%v: %w\n%s", i, err, stderr.String())
+	}
+
+	return
+}